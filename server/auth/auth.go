@@ -0,0 +1,167 @@
+// Package auth gives players a stable identity that survives
+// reconnects, backed by a pluggable UserStore and signed session
+// cookies.
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Player identifies a registered user. Unlike r.RemoteAddr, a Player's
+// ID is stable across reconnects and NAT'd clients.
+type Player struct {
+	ID          string `json:"id"`
+	DisplayName string `json:"displayName"`
+}
+
+// ErrUserExists is returned by UserStore.Register when the display
+// name is already taken.
+var ErrUserExists = errors.New("auth: user already exists")
+
+// ErrInvalidCredentials is returned by UserStore.Authenticate when the
+// display name or password don't match a registered user.
+var ErrInvalidCredentials = errors.New("auth: invalid credentials")
+
+// UserStore registers and authenticates users.
+type UserStore interface {
+	Register(displayName, password string) (Player, error)
+	Authenticate(displayName, password string) (Player, error)
+}
+
+// MemoryUserStore is an in-memory UserStore. It does not persist users
+// across restarts.
+type MemoryUserStore struct {
+	mu     sync.Mutex
+	byName map[string]memoryUser
+	nextID int
+}
+
+type memoryUser struct {
+	player Player
+	hash   []byte
+}
+
+// NewMemoryUserStore constructs an empty MemoryUserStore.
+func NewMemoryUserStore() *MemoryUserStore {
+	return &MemoryUserStore{byName: make(map[string]memoryUser)}
+}
+
+// Register implements UserStore.
+func (s *MemoryUserStore) Register(displayName, password string) (Player, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.byName[displayName]; ok {
+		return Player{}, ErrUserExists
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return Player{}, fmt.Errorf("auth: hash password: %w", err)
+	}
+
+	s.nextID++
+	u := memoryUser{
+		player: Player{ID: fmt.Sprintf("u%d", s.nextID), DisplayName: displayName},
+		hash:   hash,
+	}
+	s.byName[displayName] = u
+	return u.player, nil
+}
+
+// Authenticate implements UserStore.
+func (s *MemoryUserStore) Authenticate(displayName, password string) (Player, error) {
+	s.mu.Lock()
+	u, ok := s.byName[displayName]
+	s.mu.Unlock()
+	if !ok {
+		return Player{}, ErrInvalidCredentials
+	}
+
+	if err := bcrypt.CompareHashAndPassword(u.hash, []byte(password)); err != nil {
+		return Player{}, ErrInvalidCredentials
+	}
+	return u.player, nil
+}
+
+// sessionClaims is the payload signed into a session cookie.
+type sessionClaims struct {
+	Player Player `json:"player"`
+	Exp    int64  `json:"exp"`
+}
+
+// SessionSigner issues and verifies signed session cookie values
+// carrying a Player's stable identity.
+type SessionSigner struct {
+	secret []byte
+}
+
+// NewSessionSigner constructs a SessionSigner using secret to sign and
+// verify cookies.
+func NewSessionSigner(secret []byte) *SessionSigner {
+	return &SessionSigner{secret: secret}
+}
+
+// Sign returns a cookie value encoding player, valid until exp.
+func (s *SessionSigner) Sign(player Player, exp time.Time) (string, error) {
+	claims, err := json.Marshal(sessionClaims{Player: player, Exp: exp.Unix()})
+	if err != nil {
+		return "", fmt.Errorf("auth: marshal claims: %w", err)
+	}
+
+	payload := base64.RawURLEncoding.EncodeToString(claims)
+	sig := s.sign(payload)
+	return payload + "." + sig, nil
+}
+
+// Verify decodes and checks a cookie value produced by Sign, rejecting
+// it if the signature doesn't match or it has expired.
+func (s *SessionSigner) Verify(value string) (Player, error) {
+	payload, sig, ok := strings.Cut(value, ".")
+	if !ok {
+		return Player{}, errors.New("auth: malformed session")
+	}
+	if subtle.ConstantTimeCompare([]byte(sig), []byte(s.sign(payload))) != 1 {
+		return Player{}, errors.New("auth: invalid session signature")
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return Player{}, fmt.Errorf("auth: decode session: %w", err)
+	}
+	var claims sessionClaims
+	if err := json.Unmarshal(raw, &claims); err != nil {
+		return Player{}, fmt.Errorf("auth: unmarshal session: %w", err)
+	}
+	if time.Now().Unix() > claims.Exp {
+		return Player{}, errors.New("auth: session expired")
+	}
+	return claims.Player, nil
+}
+
+func (s *SessionSigner) sign(payload string) string {
+	h := hmac.New(sha256.New, s.secret)
+	h.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(h.Sum(nil))
+}
+
+// NewSessionSecret returns 32 random bytes suitable for NewSessionSigner.
+func NewSessionSecret() ([]byte, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, fmt.Errorf("auth: generate session secret: %w", err)
+	}
+	return secret, nil
+}
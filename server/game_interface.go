@@ -1,10 +1,31 @@
 package main
 
+import (
+	"github.com/fransk/multiplayer-games/auth"
+	"github.com/fransk/multiplayer-games/wire"
+)
+
 // A game needs to:
-// 1. receive messages from users
-// 2. decide what to do with those messages
+// 1. receive commands from users
+// 2. decide what to do with those commands
 // 3. +/- maintain internal state
 // 4. inform users of the current state
+//
+// HandleCommand processes cmd on behalf of player and returns the
+// events it produces. Each returned event is routed by the server to
+// either a single player or broadcast to the room, per its Target.
 type Game interface {
-	HandleMsg(usrid string, msg []byte)
+	HandleCommand(player auth.Player, cmd wire.Command) []wire.Event
+}
+
+// Snapshotter is optionally implemented by a Game that can serialize
+// and restore its own state, letting its room's state survive a
+// process restart instead of starting over.
+type Snapshotter interface {
+	// Snapshot returns the game's current state.
+	Snapshot() ([]byte, error)
+
+	// Restore replaces the game's state with data previously returned
+	// by Snapshot.
+	Restore(data []byte) error
 }
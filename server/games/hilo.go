@@ -1,6 +1,12 @@
 package games
 
-import "log"
+import (
+	"encoding/json"
+	"log"
+
+	"github.com/fransk/multiplayer-games/auth"
+	"github.com/fransk/multiplayer-games/wire"
+)
 
 // HiLo is a Game.
 // One player chooses an integer.
@@ -15,7 +21,83 @@ func NewHilo() *HiLo {
 	return &HiLo{magic_number: 10}
 }
 
-// methods required by main.Game
-func (*HiLo) HandleMsg(usrid string, msg []byte) {
-	log.Printf("HiLo received a message from %s: %v", usrid, msg)
+// GuessCommand is sent by a player guessing the magic number.
+type GuessCommand struct {
+	Value int `json:"value"`
+}
+
+// NetTag implements wire.Command.
+func (*GuessCommand) NetTag() string { return "hilo.guess" }
+
+// HigherEvent tells a player the magic number is higher than their guess.
+type HigherEvent struct {
+	wire.ToPlayer
+	Guess int `json:"guess"`
+}
+
+// NetTag implements wire.Event.
+func (*HigherEvent) NetTag() string { return "hilo.higher" }
+
+// LowerEvent tells a player the magic number is lower than their guess.
+type LowerEvent struct {
+	wire.ToPlayer
+	Guess int `json:"guess"`
+}
+
+// NetTag implements wire.Event.
+func (*LowerEvent) NetTag() string { return "hilo.lower" }
+
+// CorrectEvent is broadcast to the room when a player guesses the magic
+// number.
+type CorrectEvent struct {
+	wire.Broadcast
+	PlayerID string `json:"playerId"`
+	Guess    int    `json:"guess"`
+}
+
+// NetTag implements wire.Event.
+func (*CorrectEvent) NetTag() string { return "hilo.correct" }
+
+// RegisterCommands registers every command HiLo understands with reg.
+func RegisterCommands(reg *wire.CommandRegistry) {
+	reg.Register("hilo.guess", func() wire.Command { return &GuessCommand{} })
+}
+
+// HandleCommand implements main.Game.
+func (h *HiLo) HandleCommand(player auth.Player, cmd wire.Command) []wire.Event {
+	guess, ok := cmd.(*GuessCommand)
+	if !ok {
+		log.Printf("HiLo received an unsupported command from %s: %T", player.ID, cmd)
+		return nil
+	}
+
+	switch {
+	case guess.Value == h.magic_number:
+		return []wire.Event{&CorrectEvent{PlayerID: player.ID, Guess: guess.Value}}
+	case guess.Value > h.magic_number:
+		return []wire.Event{&LowerEvent{ToPlayer: wire.ToPlayer{PlayerID: player.ID}, Guess: guess.Value}}
+	default:
+		return []wire.Event{&HigherEvent{ToPlayer: wire.ToPlayer{PlayerID: player.ID}, Guess: guess.Value}}
+	}
+}
+
+// hiloSnapshot is the JSON form of a HiLo's state, as saved and
+// restored through main.Snapshotter.
+type hiloSnapshot struct {
+	MagicNumber int `json:"magicNumber"`
+}
+
+// Snapshot implements main.Snapshotter.
+func (h *HiLo) Snapshot() ([]byte, error) {
+	return json.Marshal(hiloSnapshot{MagicNumber: h.magic_number})
+}
+
+// Restore implements main.Snapshotter.
+func (h *HiLo) Restore(data []byte) error {
+	var snap hiloSnapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return err
+	}
+	h.magic_number = snap.MagicNumber
+	return nil
 }
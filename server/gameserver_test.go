@@ -0,0 +1,253 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/fransk/multiplayer-games/auth"
+	"github.com/fransk/multiplayer-games/wire"
+)
+
+// testMsgCommand/testMsgEvent give the test suite a game that simply
+// broadcasts whatever it's told, independent of any real game's rules,
+// so the transport layer can be exercised on its own.
+type testMsgCommand struct {
+	From string `json:"from"`
+	Text string `json:"text"`
+}
+
+func (*testMsgCommand) NetTag() string { return "test.msg" }
+
+type testMsgEvent struct {
+	wire.Broadcast
+	From string `json:"from"`
+	Text string `json:"text"`
+}
+
+func (*testMsgEvent) NetTag() string { return "test.msg" }
+
+type echoGame struct{}
+
+func (echoGame) HandleCommand(player auth.Player, cmd wire.Command) []wire.Event {
+	m, ok := cmd.(*testMsgCommand)
+	if !ok {
+		return nil
+	}
+	return []wire.Event{&testMsgEvent{From: m.From, Text: m.Text}}
+}
+
+// testNumClients and testNumMessages size TestGameServerConcurrent's
+// traffic. newTestGameServer sizes its subscriber buffer off these too,
+// since every client's publish fans out to every subscriber: each
+// reader must be able to hold a full run's worth of broadcasts without
+// the server kicking it for falling behind.
+const (
+	testNumClients  = 10
+	testNumMessages = 128
+)
+
+// newTestGameServer builds a gameServer whose default room runs
+// echoGame, with buffers and limiters cranked up so tests can push many
+// messages through quickly.
+func newTestGameServer() *gameServer {
+	return newGameServer(
+		withSubscriberMessageBuffer(testNumClients*testNumMessages),
+		withGame("echo", func() Game { return echoGame{} }),
+		withCommand("test.msg", func() wire.Command { return &testMsgCommand{} }),
+		withDefaultGame("echo"),
+		withListenLimiter(time.Millisecond, 256),
+		withRoomPublishLimiter(time.Millisecond, 256),
+	)
+}
+
+// testClient wraps a WebSocket connection to a running gameServer.
+type testClient struct {
+	t    *testing.T
+	conn *websocket.Conn
+}
+
+// registerTestPlayer registers a fresh account against srvURL and
+// returns its session cookie value.
+func registerTestPlayer(t *testing.T, srvURL, displayName string) string {
+	t.Helper()
+
+	body, err := json.Marshal(credentialsRequest{DisplayName: displayName, Password: "password"})
+	if err != nil {
+		t.Fatalf("marshal credentials: %v", err)
+	}
+
+	resp, err := http.Post(srvURL+"/register", "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("register: unexpected status %v", resp.Status)
+	}
+
+	for _, c := range resp.Cookies() {
+		if c.Name == sessionCookieName {
+			return c.Value
+		}
+	}
+	t.Fatalf("register: no session cookie in response")
+	return ""
+}
+
+func dialTestClient(t *testing.T, srvURL, displayName string) *testClient {
+	t.Helper()
+
+	session := registerTestPlayer(t, srvURL, displayName)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	header := http.Header{}
+	header.Set("Cookie", sessionCookieName+"="+session)
+
+	url := "ws" + strings.TrimPrefix(srvURL, "http") + "/subscribe"
+	c, _, err := websocket.Dial(ctx, url, &websocket.DialOptions{HTTPHeader: header})
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	return &testClient{t: t, conn: c}
+}
+
+// publish sends a testMsgCommand envelope over the connection.
+func (tc *testClient) publish(from, text string) {
+	tc.t.Helper()
+
+	body, err := json.Marshal(testMsgCommand{From: from, Text: text})
+	if err != nil {
+		tc.t.Fatalf("marshal body: %v", err)
+	}
+	data, err := json.Marshal(wire.Envelope{Type: "test.msg", Body: body})
+	if err != nil {
+		tc.t.Fatalf("marshal envelope: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := tc.conn.Write(ctx, websocket.MessageText, data); err != nil {
+		tc.t.Fatalf("write: %v", err)
+	}
+}
+
+// readUntil reads events off the connection until every key in want
+// has been observed, or ctx is done.
+func (tc *testClient) readUntil(ctx context.Context, want map[string]struct{}) error {
+	seen := make(map[string]struct{}, len(want))
+	for len(seen) < len(want) {
+		_, body, err := tc.conn.Reader(ctx)
+		if err != nil {
+			return fmt.Errorf("read: %w", err)
+		}
+		raw, err := io.ReadAll(body)
+		if err != nil {
+			return fmt.Errorf("read body: %w", err)
+		}
+
+		var env wire.Envelope
+		if err := json.Unmarshal(raw, &env); err != nil {
+			return fmt.Errorf("unmarshal envelope: %w", err)
+		}
+		var evt testMsgEvent
+		if err := json.Unmarshal(env.Body, &evt); err != nil {
+			return fmt.Errorf("unmarshal body: %w", err)
+		}
+
+		seen[evt.From+":"+evt.Text] = struct{}{}
+	}
+	return nil
+}
+
+func (tc *testClient) close() {
+	tc.conn.Close(websocket.StatusNormalClosure, "")
+}
+
+// TestGameServerEcho is a smoke test: one client publishes one message
+// and must see it broadcast back.
+func TestGameServerEcho(t *testing.T) {
+	srv := httptest.NewServer(newTestGameServer())
+	defer srv.Close()
+
+	c := dialTestClient(t, srv.URL, "alice")
+	defer c.close()
+
+	c.publish("alice", "hello")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := c.readUntil(ctx, map[string]struct{}{"alice:hello": {}}); err != nil {
+		t.Fatalf("readUntil: %v", err)
+	}
+}
+
+// TestGameServerConcurrent spins up several clients that each publish
+// many unique messages and verifies every client observes every
+// message published by every client, itself included.
+func TestGameServerConcurrent(t *testing.T) {
+	const (
+		numClients  = testNumClients
+		numMessages = testNumMessages
+	)
+
+	srv := httptest.NewServer(newTestGameServer())
+	defer srv.Close()
+
+	clients := make([]*testClient, numClients)
+	for i := range clients {
+		clients[i] = dialTestClient(t, srv.URL, fmt.Sprintf("player%d", i))
+		defer clients[i].close()
+	}
+
+	want := make(map[string]struct{}, numClients*numMessages)
+	for i := range clients {
+		for j := 0; j < numMessages; j++ {
+			want[fmt.Sprintf("client%d:msg%d", i, j)] = struct{}{}
+		}
+	}
+
+	var publishers sync.WaitGroup
+	for i, c := range clients {
+		publishers.Add(1)
+		go func(i int, c *testClient) {
+			defer publishers.Done()
+			for j := 0; j < numMessages; j++ {
+				c.publish(fmt.Sprintf("client%d", i), fmt.Sprintf("msg%d", j))
+			}
+		}(i, c)
+	}
+	publishers.Wait()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	var readers sync.WaitGroup
+	errs := make(chan error, numClients)
+	for _, c := range clients {
+		readers.Add(1)
+		go func(c *testClient) {
+			defer readers.Done()
+			errs <- c.readUntil(ctx, want)
+		}(c)
+	}
+	readers.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Error(err)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"sort"
+	"sync"
+)
+
+// GameRegistry tracks the games a gameServer knows how to start,
+// keyed by name (e.g. "hilo"). Rooms are created from a registered
+// factory instead of the server having a single game hardcoded at boot.
+type GameRegistry struct {
+	mu        sync.Mutex
+	factories map[string]func() Game
+}
+
+// newGameRegistry constructs an empty GameRegistry.
+func newGameRegistry() *GameRegistry {
+	return &GameRegistry{factories: make(map[string]func() Game)}
+}
+
+// Register adds a game factory under name, overwriting any existing
+// factory registered under the same name.
+func (gr *GameRegistry) Register(name string, factory func() Game) {
+	gr.mu.Lock()
+	defer gr.mu.Unlock()
+	gr.factories[name] = factory
+}
+
+// New starts a new Game instance for name. ok is false if name hasn't
+// been registered.
+func (gr *GameRegistry) New(name string) (game Game, ok bool) {
+	gr.mu.Lock()
+	factory, ok := gr.factories[name]
+	gr.mu.Unlock()
+	if !ok {
+		return nil, false
+	}
+	return factory(), true
+}
+
+// Names returns the names of every registered game, sorted so callers
+// (e.g. the subprotocol list offered to a WebSocket client) get a
+// stable order instead of however the backing map happened to iterate.
+func (gr *GameRegistry) Names() []string {
+	gr.mu.Lock()
+	defer gr.mu.Unlock()
+
+	names := make([]string, 0, len(gr.factories))
+	for name := range gr.factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/time/rate"
+
+	"github.com/fransk/multiplayer-games/store"
+	"github.com/fransk/multiplayer-games/wire"
+)
+
+// defaultRoomID is used when a client does not specify a room.
+const defaultRoomID = "default"
+
+// roomHistoryLimit bounds how many broadcast events a Room keeps around
+// for SSE clients resuming with Last-Event-ID.
+const roomHistoryLimit = 256
+
+// Sink receives the messages a Room routes to a single subscriber,
+// regardless of the transport (WebSocket, SSE, ...) delivering them.
+type Sink interface {
+	// Send enqueues msg for delivery, returning false if the sink
+	// can't keep up and the message was dropped.
+	Send(msg []byte) bool
+
+	// PlayerID returns the player this sink delivers to.
+	PlayerID() string
+}
+
+// Room owns a single Game and the set of subscribers currently
+// watching it. Messages published to a room are only fanned out
+// to that room's subscribers, which lets a gameServer host many
+// concurrent tables instead of a single global game.
+type Room struct {
+	id string
+
+	// gameName is the registered name of the game this room is running,
+	// e.g. "hilo".
+	gameName string
+
+	// game is the Game instance this room is running.
+	game Game
+
+	// publishLimiter controls the rate limit applied to publishes
+	// within this room.
+	publishLimiter *rate.Limiter
+
+	// store records every event this room dispatches, so the room's
+	// history survives beyond what's kept in memory.
+	store store.Store
+
+	subscribersMu sync.Mutex
+	subscribers   map[Sink]struct{}
+
+	// seq is the monotonic counter used to number outgoing wire events.
+	seq int64
+
+	historyMu sync.Mutex
+	history   []historyEvent
+}
+
+// historyEvent is a previously broadcast event kept around so SSE
+// clients reconnecting with Last-Event-ID can catch up.
+type historyEvent struct {
+	seq  int
+	data []byte
+}
+
+// newRoom constructs a Room running the given game under gameName,
+// rate-limiting its publishes to one every publishEvery with a burst of
+// publishBurst. Every event the room dispatches is appended to st.
+func newRoom(id, gameName string, game Game, publishEvery time.Duration, publishBurst int, st store.Store) *Room {
+	return &Room{
+		id:             id,
+		gameName:       gameName,
+		game:           game,
+		publishLimiter: rate.NewLimiter(rate.Every(publishEvery), publishBurst),
+		store:          st,
+		subscribers:    make(map[Sink]struct{}),
+	}
+}
+
+// addSubscriber registers a sink with this room.
+func (rm *Room) addSubscriber(s Sink) {
+	rm.subscribersMu.Lock()
+	rm.subscribers[s] = struct{}{}
+	rm.subscribersMu.Unlock()
+}
+
+// deleteSubscriber removes a sink from this room.
+func (rm *Room) deleteSubscriber(s Sink) {
+	rm.subscribersMu.Lock()
+	delete(rm.subscribers, s)
+	rm.subscribersMu.Unlock()
+}
+
+// publish publishes the msg to all subscribers of this room. It waits
+// on the room's publish limiter, but never blocks on a subscriber, so
+// messages to slow subscribers are dropped. It does not record msg in
+// the room's history; dispatch does that before calling publish.
+func (rm *Room) publish(msg []byte) {
+	rm.subscribersMu.Lock()
+	defer rm.subscribersMu.Unlock()
+
+	rm.publishLimiter.Wait(context.Background())
+
+	for s := range rm.subscribers {
+		s.Send(msg)
+	}
+}
+
+// sendTo delivers msg to the subscriber(s) registered under playerID,
+// dropping it for any that can't keep up.
+func (rm *Room) sendTo(playerID string, msg []byte) {
+	rm.subscribersMu.Lock()
+	defer rm.subscribersMu.Unlock()
+
+	for s := range rm.subscribers {
+		if s.PlayerID() == playerID {
+			s.Send(msg)
+		}
+	}
+}
+
+// record appends data to the room's broadcast history, trimming it
+// back down to roomHistoryLimit entries.
+func (rm *Room) record(seq int, data []byte) {
+	rm.historyMu.Lock()
+	defer rm.historyMu.Unlock()
+
+	rm.history = append(rm.history, historyEvent{seq: seq, data: data})
+	if len(rm.history) > roomHistoryLimit {
+		rm.history = rm.history[len(rm.history)-roomHistoryLimit:]
+	}
+}
+
+// eventsSince returns the broadcast history recorded after lastSeq, in
+// order, for an SSE client resuming with Last-Event-ID.
+func (rm *Room) eventsSince(lastSeq int) [][]byte {
+	rm.historyMu.Lock()
+	defer rm.historyMu.Unlock()
+
+	var out [][]byte
+	for _, e := range rm.history {
+		if e.seq > lastSeq {
+			out = append(out, e.data)
+		}
+	}
+	return out
+}
+
+// dispatch encodes each event and routes it to its Target, or
+// broadcasts it to the room (and records it in history) if Target is
+// empty.
+func (rm *Room) dispatch(events []wire.Event) {
+	for _, evt := range events {
+		seq := int(atomic.AddInt64(&rm.seq, 1))
+		data, err := wire.Encode(seq, evt)
+		if err != nil {
+			log.Printf("room %s: failed to encode %T: %v", rm.id, evt, err)
+			continue
+		}
+		if err := rm.store.AppendEvent(rm.id, data); err != nil {
+			log.Printf("room %s: failed to append event: %v", rm.id, err)
+		}
+		if target := evt.Target(); target != "" {
+			rm.sendTo(target, data)
+			continue
+		}
+		rm.record(seq, data)
+		rm.publish(data)
+	}
+}
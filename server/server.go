@@ -2,20 +2,52 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"log"
 	"net"
 	"net/http"
+	"regexp"
+	"strings"
 	"sync"
 	"time"
 
 	"golang.org/x/time/rate"
 
 	"github.com/coder/websocket"
+	"github.com/fransk/multiplayer-games/auth"
 	"github.com/fransk/multiplayer-games/games"
+	"github.com/fransk/multiplayer-games/store"
+	"github.com/fransk/multiplayer-games/wire"
 )
 
+// sessionCookieName is the cookie carrying a player's signed session.
+const sessionCookieName = "session"
+
+// sessionDuration is how long a session cookie remains valid.
+const sessionDuration = 24 * time.Hour
+
+// gameSubprotocolPrefix marks the WebSocket subprotocols offered during
+// the handshake that identify a registered game, e.g. "game.hilo".
+const gameSubprotocolPrefix = "game."
+
+// builtinDefaultGameName is the game started for the default room and
+// for any new room created without specifying which game to play.
+const builtinDefaultGameName = "hilo"
+
+// roomIDPattern restricts the room ids accepted from a request. Room
+// ids end up as path components in a filesystem-backed Store, so
+// anything outside this charset (e.g. "../../etc/passwd") is rejected
+// rather than reaching getOrCreateRoom/Store.
+var roomIDPattern = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+
+// validRoomID reports whether id is safe to accept as a room id.
+func validRoomID(id string) bool {
+	return roomIDPattern.MatchString(id)
+}
+
 // gameServer enables broadcasting to a set of subscribers.
 type gameServer struct {
 	// subscriberMessageBuffer controls the max number
@@ -25,10 +57,23 @@ type gameServer struct {
 	// Defaults to 16.
 	subscriberMessageBuffer int
 
-	// publishLimiter controls the rate limit applied to the publish endpoint.
+	// listenEvery and listenBurst rate-limit how fast a single
+	// connection's incoming messages are processed.
 	//
-	// Defaults to one publish every 100ms with a burst of 8.
-	publishLimiter *rate.Limiter
+	// Default to one message every 100ms with a burst of 10.
+	listenEvery time.Duration
+	listenBurst int
+
+	// roomPublishEvery and roomPublishBurst configure the rate limiter
+	// given to every room this server creates.
+	//
+	// Default to one publish every 100ms with a burst of 8.
+	roomPublishEvery time.Duration
+	roomPublishBurst int
+
+	// defaultGameName is the game started for the default room and for
+	// any new room created without specifying which game to play.
+	defaultGameName string
 
 	// logf controls where logs are sent.
 	// Defaults to log.Printf.
@@ -37,38 +82,417 @@ type gameServer struct {
 	// serveMux routes the various endpoints to the appropriate handler.
 	serveMux http.ServeMux
 
-	subscribersMu sync.Mutex
-	subscribers   map[*subscriber]struct{}
+	roomsMu sync.Mutex
+	rooms   map[string]*Room
+
+	// registry holds the games clients are allowed to start rooms for.
+	registry *GameRegistry
+
+	// commands decodes incoming wire envelopes into the Command a
+	// room's game expects.
+	commands *wire.CommandRegistry
+
+	// users stores registered accounts.
+	users auth.UserStore
+
+	// sessions signs and verifies the session cookies issued on
+	// register/login and checked on every subscribe/publish.
+	sessions *auth.SessionSigner
+
+	// store persists room game state, so a Game implementing
+	// Snapshotter survives a process restart instead of resetting.
+	store store.Store
+}
+
+// gameServerOption configures a gameServer built by newGameServer.
+// Tests use these to crank up limits or register fixtures; production
+// code can rely on the defaults.
+type gameServerOption func(*gameServer)
+
+// withSubscriberMessageBuffer overrides subscriberMessageBuffer.
+func withSubscriberMessageBuffer(n int) gameServerOption {
+	return func(cs *gameServer) { cs.subscriberMessageBuffer = n }
+}
+
+// withListenLimiter overrides the per-connection read rate limit.
+func withListenLimiter(every time.Duration, burst int) gameServerOption {
+	return func(cs *gameServer) { cs.listenEvery, cs.listenBurst = every, burst }
+}
+
+// withRoomPublishLimiter overrides the rate limit given to every room.
+func withRoomPublishLimiter(every time.Duration, burst int) gameServerOption {
+	return func(cs *gameServer) { cs.roomPublishEvery, cs.roomPublishBurst = every, burst }
+}
+
+// withGame registers an additional game factory.
+func withGame(name string, factory func() Game) gameServerOption {
+	return func(cs *gameServer) { cs.registry.Register(name, factory) }
+}
+
+// withCommand registers an additional wire command.
+func withCommand(tag string, factory func() wire.Command) gameServerOption {
+	return func(cs *gameServer) { cs.commands.Register(tag, factory) }
+}
+
+// withDefaultGame overrides the game started for the default room.
+func withDefaultGame(name string) gameServerOption {
+	return func(cs *gameServer) { cs.defaultGameName = name }
+}
 
-	// game is the currently loaded game
-	game Game
+// withUserStore overrides the UserStore backing /register and /login.
+func withUserStore(users auth.UserStore) gameServerOption {
+	return func(cs *gameServer) { cs.users = users }
 }
 
-// newGameServer constructs a gameServer with the defaults.
-func newGameServer() *gameServer {
+// withSessionSigner overrides the signer used for session cookies.
+func withSessionSigner(sessions *auth.SessionSigner) gameServerOption {
+	return func(cs *gameServer) { cs.sessions = sessions }
+}
+
+// withStore overrides the Store backing room snapshots and event
+// history.
+func withStore(st store.Store) gameServerOption {
+	return func(cs *gameServer) { cs.store = st }
+}
+
+// newGameServer constructs a gameServer with the defaults, applying any
+// opts on top.
+func newGameServer(opts ...gameServerOption) *gameServer {
+	secret, err := auth.NewSessionSecret()
+	if err != nil {
+		panic(err)
+	}
+
 	cs := &gameServer{
 		subscriberMessageBuffer: 16,
+		listenEvery:             time.Millisecond * 100,
+		listenBurst:             10,
+		roomPublishEvery:        time.Millisecond * 100,
+		roomPublishBurst:        8,
+		defaultGameName:         builtinDefaultGameName,
 		logf:                    log.Printf,
-		subscribers:             make(map[*subscriber]struct{}),
-		publishLimiter:          rate.NewLimiter(rate.Every(time.Millisecond*100), 8),
-		game:                    games.NewHilo(), // TODO: let user choose the game
+		rooms:                   make(map[string]*Room),
+		registry:                newGameRegistry(),
+		commands:                wire.NewCommandRegistry(),
+		users:                   auth.NewMemoryUserStore(),
+		sessions:                auth.NewSessionSigner(secret),
+		store:                   store.NewMemoryStore(),
+	}
+	cs.registry.Register("hilo", func() Game { return games.NewHilo() })
+	games.RegisterCommands(cs.commands)
+
+	for _, opt := range opts {
+		opt(cs)
 	}
+
+	cs.restoreRooms()
+
+	if _, ok := cs.rooms[defaultRoomID]; !ok {
+		defaultGame, ok := cs.registry.New(cs.defaultGameName)
+		if !ok {
+			panic("newGameServer: unknown default game " + cs.defaultGameName)
+		}
+		cs.rooms[defaultRoomID] = newRoom(defaultRoomID, cs.defaultGameName, defaultGame, cs.roomPublishEvery, cs.roomPublishBurst, cs.store)
+	}
+
 	cs.serveMux.Handle("/", http.FileServer(http.Dir("../web")))
 	cs.serveMux.HandleFunc("/subscribe", cs.subscribeHandler)
 	cs.serveMux.HandleFunc("/publish", cs.publishHandler)
+	cs.serveMux.HandleFunc("/lobby", cs.lobbyHandler)
+	cs.serveMux.HandleFunc("/rooms", cs.createRoomHandler)
+	cs.serveMux.HandleFunc("/register", cs.registerHandler)
+	cs.serveMux.HandleFunc("/login", cs.loginHandler)
+	cs.serveMux.HandleFunc("/events", cs.eventsHandler)
 
 	return cs
 }
 
-// subscriber represents a subscriber.
+// credentialsRequest is the body expected by /register and /login.
+type credentialsRequest struct {
+	DisplayName string `json:"displayName"`
+	Password    string `json:"password"`
+}
+
+// registerHandler creates a new account and starts a session for it.
+func (cs *gameServer) registerHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req credentialsRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 8192)).Decode(&req); err != nil || req.DisplayName == "" || req.Password == "" {
+		http.Error(w, "displayName and password are required", http.StatusBadRequest)
+		return
+	}
+
+	player, err := cs.users.Register(req.DisplayName, req.Password)
+	if err != nil {
+		if errors.Is(err, auth.ErrUserExists) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	cs.startSession(w, player)
+}
+
+// loginHandler authenticates an existing account and starts a session
+// for it.
+func (cs *gameServer) loginHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req credentialsRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 8192)).Decode(&req); err != nil || req.DisplayName == "" || req.Password == "" {
+		http.Error(w, "displayName and password are required", http.StatusBadRequest)
+		return
+	}
+
+	player, err := cs.users.Authenticate(req.DisplayName, req.Password)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	cs.startSession(w, player)
+}
+
+// startSession signs a session cookie for player, sets it on w, and
+// writes player as the JSON response.
+func (cs *gameServer) startSession(w http.ResponseWriter, player auth.Player) {
+	value, err := cs.sessions.Sign(player, time.Now().Add(sessionDuration))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookieName,
+		Value:    value,
+		Path:     "/",
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+		Expires:  time.Now().Add(sessionDuration),
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(player)
+}
+
+// playerFromRequest verifies the session cookie on r and returns the
+// Player it identifies.
+func (cs *gameServer) playerFromRequest(r *http.Request) (auth.Player, error) {
+	cookie, err := r.Cookie(sessionCookieName)
+	if err != nil {
+		return auth.Player{}, errors.New("auth: missing session cookie")
+	}
+	return cs.sessions.Verify(cookie.Value)
+}
+
+// subscriber represents a subscriber connected over WebSocket.
 // Messages are sent on the msgs channel and if the client
 // cannot keep up with the messages, closeSlow is called.
 type subscriber struct {
 	id        string
+	roomID    string
 	msgs      chan []byte
 	closeSlow func()
 }
 
+// Send implements Sink.
+func (s *subscriber) Send(msg []byte) bool {
+	select {
+	case s.msgs <- msg:
+		return true
+	default:
+		go s.closeSlow()
+		return false
+	}
+}
+
+// PlayerID implements Sink.
+func (s *subscriber) PlayerID() string { return s.id }
+
+// roomIDFromRequest returns the room the request is addressed to,
+// falling back to defaultRoomID when none is specified. It errors if a
+// specified room id fails validRoomID.
+func roomIDFromRequest(r *http.Request) (string, error) {
+	id := r.URL.Query().Get("room")
+	if id == "" {
+		return defaultRoomID, nil
+	}
+	if !validRoomID(id) {
+		return "", fmt.Errorf("invalid room id %q", id)
+	}
+	return id, nil
+}
+
+// getOrCreateRoom returns the room with the given id, lazily starting
+// a new room running gameName if one doesn't exist yet. ok is false if
+// gameName isn't registered and the room didn't already exist.
+//
+// gameName is only used to pick the game for a new room: if a room
+// already exists under id, it's returned as-is even if it's running a
+// different game. Callers that negotiated a specific game (subscribe's
+// subprotocol) must check rm.gameName themselves and reject the
+// mismatch; callers that just want some room to exist (publish, SSE)
+// can pass cs.defaultGameName and rely on that fallback only mattering
+// for brand-new rooms.
+func (cs *gameServer) getOrCreateRoom(id, gameName string) (rm *Room, ok bool) {
+	cs.roomsMu.Lock()
+	defer cs.roomsMu.Unlock()
+
+	if rm, ok := cs.rooms[id]; ok {
+		return rm, true
+	}
+
+	game, ok := cs.registry.New(gameName)
+	if !ok {
+		return nil, false
+	}
+	cs.restoreGame(game, id)
+	rm = newRoom(id, gameName, game, cs.roomPublishEvery, cs.roomPublishBurst, cs.store)
+	cs.rooms[id] = rm
+	return rm, true
+}
+
+// restoreRooms rebuilds cs.rooms from every room cs.store has a
+// persisted snapshot for, so rooms beyond the hardcoded default survive
+// a process restart too. A record whose game is no longer registered
+// is skipped and logged rather than restored into the wrong game.
+func (cs *gameServer) restoreRooms() {
+	records, err := cs.store.Rooms()
+	if err != nil {
+		cs.logf("failed to list persisted rooms: %v", err)
+		return
+	}
+
+	for _, rec := range records {
+		game, ok := cs.registry.New(rec.Game)
+		if !ok {
+			cs.logf("room %s: skipping restore, unknown game %q", rec.RoomID, rec.Game)
+			continue
+		}
+		cs.restoreGame(game, rec.RoomID)
+		cs.rooms[rec.RoomID] = newRoom(rec.RoomID, rec.Game, game, cs.roomPublishEvery, cs.roomPublishBurst, cs.store)
+	}
+}
+
+// restoreGame restores game's state from the last snapshot saved under
+// roomID, if game is a Snapshotter and a snapshot exists. Failures are
+// logged, not fatal: a room missing its prior state still starts, just
+// as if it were new.
+func (cs *gameServer) restoreGame(game Game, roomID string) {
+	snap, ok := game.(Snapshotter)
+	if !ok {
+		return
+	}
+
+	data, err := cs.store.LoadGame(roomID)
+	if err != nil {
+		if !errors.Is(err, store.ErrNotFound) {
+			cs.logf("room %s: load snapshot failed: %v", roomID, err)
+		}
+		return
+	}
+	if err := snap.Restore(data); err != nil {
+		cs.logf("room %s: restore snapshot failed: %v", roomID, err)
+	}
+}
+
+// snapshotRoom saves rm.game's current state to cs.store, if rm.game
+// is a Snapshotter. It's called after every command dispatched to the
+// room, so the room's state survives a restart.
+func (cs *gameServer) snapshotRoom(rm *Room) {
+	snap, ok := rm.game.(Snapshotter)
+	if !ok {
+		return
+	}
+
+	data, err := snap.Snapshot()
+	if err != nil {
+		cs.logf("room %s: snapshot failed: %v", rm.id, err)
+		return
+	}
+	if err := cs.store.SaveGame(rm.id, rm.gameName, data); err != nil {
+		cs.logf("room %s: save snapshot failed: %v", rm.id, err)
+	}
+}
+
+// lobbyHandler reports the games available to play and the rooms
+// currently running.
+func (cs *gameServer) lobbyHandler(w http.ResponseWriter, r *http.Request) {
+	type roomInfo struct {
+		ID   string `json:"id"`
+		Game string `json:"game"`
+	}
+
+	cs.roomsMu.Lock()
+	rooms := make([]roomInfo, 0, len(cs.rooms))
+	for _, rm := range cs.rooms {
+		rooms = append(rooms, roomInfo{ID: rm.id, Game: rm.gameName})
+	}
+	cs.roomsMu.Unlock()
+
+	resp := struct {
+		Games []string   `json:"games"`
+		Rooms []roomInfo `json:"rooms"`
+	}{
+		Games: cs.registry.Names(),
+		Rooms: rooms,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// createRoomHandler creates a new room running a registered game.
+func (cs *gameServer) createRoomHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		ID   string `json:"id"`
+		Game string `json:"game"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 8192)).Decode(&req); err != nil || req.ID == "" || req.Game == "" {
+		http.Error(w, "id and game are required", http.StatusBadRequest)
+		return
+	}
+	if !validRoomID(req.ID) {
+		http.Error(w, "invalid room id", http.StatusBadRequest)
+		return
+	}
+
+	cs.roomsMu.Lock()
+	_, exists := cs.rooms[req.ID]
+	cs.roomsMu.Unlock()
+	if exists {
+		http.Error(w, "room already exists", http.StatusConflict)
+		return
+	}
+
+	rm, ok := cs.getOrCreateRoom(req.ID, req.Game)
+	if !ok {
+		http.Error(w, "unknown game: "+req.Game, http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(struct {
+		ID   string `json:"id"`
+		Game string `json:"game"`
+	}{ID: rm.id, Game: rm.gameName})
+}
+
 func (cs *gameServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	cs.serveMux.ServeHTTP(w, r)
 }
@@ -98,6 +522,13 @@ func (cs *gameServer) publishHandler(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
 		return
 	}
+
+	player, err := cs.playerFromRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
 	body := http.MaxBytesReader(w, r.Body, 8192)
 	msg, err := io.ReadAll(body)
 	if err != nil {
@@ -105,11 +536,27 @@ func (cs *gameServer) publishHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// send the message to the game
-	cs.game.HandleMsg(r.RemoteAddr, msg)
+	roomID, err := roomIDFromRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	rm, ok := cs.getOrCreateRoom(roomID, cs.defaultGameName)
+	if !ok {
+		http.Error(w, "unknown game: "+cs.defaultGameName, http.StatusInternalServerError)
+		return
+	}
 
-	// update the other users
-	cs.publish(msg)
+	cmd, err := cs.commands.Decode(msg)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// run the command against the room's game, route whatever events
+	// it produces, and persist the game's resulting state
+	rm.dispatch(rm.game.HandleCommand(player, cmd))
+	cs.snapshotRoom(rm)
 
 	w.WriteHeader(http.StatusAccepted)
 }
@@ -123,14 +570,53 @@ func (cs *gameServer) publishHandler(w http.ResponseWriter, r *http.Request) {
 // It uses CloseRead to keep reading from the connection to process control
 // messages and cancel the context if the connection drops.
 func (cs *gameServer) subscribe(w http.ResponseWriter, r *http.Request) error {
+	roomID, err := roomIDFromRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil
+	}
+
+	subprotocols := make([]string, 0, len(cs.registry.Names()))
+	for _, name := range cs.registry.Names() {
+		subprotocols = append(subprotocols, gameSubprotocolPrefix+name)
+	}
+
+	c2, err := websocket.Accept(w, r, &websocket.AcceptOptions{Subprotocols: subprotocols})
+	if err != nil {
+		return err
+	}
+	defer c2.CloseNow()
+
+	player, err := cs.playerFromRequest(r)
+	if err != nil {
+		c2.Close(websocket.StatusPolicyViolation, err.Error())
+		return nil
+	}
+
+	gameName := strings.TrimPrefix(c2.Subprotocol(), gameSubprotocolPrefix)
+	requestedGame := gameName != ""
+	if !requestedGame {
+		gameName = cs.defaultGameName
+	}
+	rm, ok := cs.getOrCreateRoom(roomID, gameName)
+	if !ok {
+		c2.Close(websocket.StatusPolicyViolation, "unknown game: "+gameName)
+		return nil
+	}
+	if requestedGame && rm.gameName != gameName {
+		c2.Close(websocket.StatusPolicyViolation, fmt.Sprintf("room %s is running %s, not %s", rm.id, rm.gameName, gameName))
+		return nil
+	}
+
 	var mu sync.Mutex
 	var c *websocket.Conn
 	var closed bool
 	s := &subscriber{
-		id:   r.RemoteAddr,
-		msgs: make(chan []byte, cs.subscriberMessageBuffer),
+		id:     player.ID,
+		roomID: rm.id,
+		msgs:   make(chan []byte, cs.subscriberMessageBuffer),
 		closeSlow: func() {
-			log.Printf("calling close on subscriber with id %v", r.RemoteAddr)
+			log.Printf("calling close on subscriber with id %v", player.ID)
 			mu.Lock()
 			defer mu.Unlock()
 			closed = true
@@ -139,13 +625,9 @@ func (cs *gameServer) subscribe(w http.ResponseWriter, r *http.Request) error {
 			}
 		},
 	}
-	cs.addSubscriber(s)
-	defer cs.deleteSubscriber(s)
+	rm.addSubscriber(s)
+	defer rm.deleteSubscriber(s)
 
-	c2, err := websocket.Accept(w, r, nil)
-	if err != nil {
-		return err
-	}
 	mu.Lock()
 	if closed {
 		mu.Unlock()
@@ -153,13 +635,12 @@ func (cs *gameServer) subscribe(w http.ResponseWriter, r *http.Request) error {
 	}
 	c = c2
 	mu.Unlock()
-	defer c.CloseNow()
 
 	ctx, cancel := context.WithTimeout(context.Background(), time.Minute*60)
 	defer cancel()
 
-	l := rate.NewLimiter(rate.Every(time.Millisecond*100), 10)
-	go cs.listen(ctx, w, r, c, l)
+	l := rate.NewLimiter(rate.Every(cs.listenEvery), cs.listenBurst)
+	go cs.listen(ctx, w, r, c, l, rm, player)
 
 	for {
 		select {
@@ -175,20 +656,20 @@ func (cs *gameServer) subscribe(w http.ResponseWriter, r *http.Request) error {
 	}
 }
 
-func (cs *gameServer) listen(ctx context.Context, w http.ResponseWriter, r *http.Request, c *websocket.Conn, l *rate.Limiter) error {
+func (cs *gameServer) listen(ctx context.Context, w http.ResponseWriter, r *http.Request, c *websocket.Conn, l *rate.Limiter, rm *Room, player auth.Player) error {
 	for {
-		err := cs.echo(ctx, w, r, c, l)
+		err := cs.echo(ctx, w, r, c, l, rm, player)
 		if websocket.CloseStatus(err) == websocket.StatusNormalClosure {
 			return nil
 		}
 		if err != nil {
-			cs.logf("failed to echo with %v: %v", r.RemoteAddr, err)
+			cs.logf("failed to echo with %v: %v", player.ID, err)
 			return err
 		}
 	}
 }
 
-func (cs *gameServer) echo(ctx context.Context, w http.ResponseWriter, r *http.Request, c *websocket.Conn, l *rate.Limiter) error {
+func (cs *gameServer) echo(ctx context.Context, w http.ResponseWriter, r *http.Request, c *websocket.Conn, l *rate.Limiter, rm *Room, player auth.Player) error {
 	err := l.Wait(ctx)
 	if err != nil {
 		return err
@@ -207,48 +688,21 @@ func (cs *gameServer) echo(ctx context.Context, w http.ResponseWriter, r *http.R
 	}
 	log.Printf("msg: %s", msg)
 
-	// send the message to the game
-	cs.game.HandleMsg(r.RemoteAddr, msg)
+	cmd, err := cs.commands.Decode(msg)
+	if err != nil {
+		cs.logf("failed to decode command from %v: %v", player.ID, err)
+		return nil
+	}
 
-	// update the other users
-	cs.publish(msg)
+	// run the command against the room's game, route whatever events
+	// it produces, and persist the game's resulting state
+	rm.dispatch(rm.game.HandleCommand(player, cmd))
+	cs.snapshotRoom(rm)
 
 	// no errors
 	return nil
 }
 
-// publish publishes the msg to all subscribers.
-// It never blocks and so messages to slow subscribers
-// are dropped.
-func (cs *gameServer) publish(msg []byte) {
-	cs.subscribersMu.Lock()
-	defer cs.subscribersMu.Unlock()
-
-	cs.publishLimiter.Wait(context.Background())
-
-	for s := range cs.subscribers {
-		select {
-		case s.msgs <- msg:
-		default:
-			go s.closeSlow()
-		}
-	}
-}
-
-// addSubscriber registers a subscriber.
-func (cs *gameServer) addSubscriber(s *subscriber) {
-	cs.subscribersMu.Lock()
-	cs.subscribers[s] = struct{}{}
-	cs.subscribersMu.Unlock()
-}
-
-// deleteSubscriber deletes the given subscriber.
-func (cs *gameServer) deleteSubscriber(s *subscriber) {
-	cs.subscribersMu.Lock()
-	delete(cs.subscribers, s)
-	cs.subscribersMu.Unlock()
-}
-
 func writeTimeout(ctx context.Context, timeout time.Duration, c *websocket.Conn, msg []byte) error {
 	ctx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
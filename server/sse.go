@@ -0,0 +1,104 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/fransk/multiplayer-games/wire"
+)
+
+// sseSubscriber is a Sink that delivers a room's broadcasts over
+// Server-Sent Events instead of a WebSocket.
+type sseSubscriber struct {
+	id   string
+	msgs chan []byte
+}
+
+// newSSESubscriber constructs an sseSubscriber for player with the
+// given outbox buffer size.
+func newSSESubscriber(playerID string, buffer int) *sseSubscriber {
+	return &sseSubscriber{id: playerID, msgs: make(chan []byte, buffer)}
+}
+
+// Send implements Sink.
+func (s *sseSubscriber) Send(msg []byte) bool {
+	select {
+	case s.msgs <- msg:
+		return true
+	default:
+		return false
+	}
+}
+
+// PlayerID implements Sink.
+func (s *sseSubscriber) PlayerID() string { return s.id }
+
+// eventsHandler streams a room's broadcast events over SSE. Clients
+// reconnecting with a Last-Event-ID header are replayed anything the
+// room still has in its history before switching to live events.
+func (cs *gameServer) eventsHandler(w http.ResponseWriter, r *http.Request) {
+	player, err := cs.playerFromRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	roomID, err := roomIDFromRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	rm, ok := cs.getOrCreateRoom(roomID, cs.defaultGameName)
+	if !ok {
+		http.Error(w, "unknown game: "+cs.defaultGameName, http.StatusInternalServerError)
+		return
+	}
+
+	s := newSSESubscriber(player.ID, cs.subscriberMessageBuffer)
+	rm.addSubscriber(s)
+	defer rm.deleteSubscriber(s)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+
+	lastSeq, _ := strconv.Atoi(r.Header.Get("Last-Event-ID"))
+	for _, data := range rm.eventsSince(lastSeq) {
+		if err := writeSSEEvent(w, data); err != nil {
+			return
+		}
+	}
+	flusher.Flush()
+
+	ctx := r.Context()
+	for {
+		select {
+		case msg := <-s.msgs:
+			if err := writeSSEEvent(w, msg); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// writeSSEEvent writes data as an SSE "event" frame, tagging it with
+// its wire sequence number as the event id so clients can resume with
+// Last-Event-ID.
+func writeSSEEvent(w http.ResponseWriter, data []byte) error {
+	var env wire.Envelope
+	_ = json.Unmarshal(data, &env) // best effort; seq defaults to 0 on failure
+
+	_, err := fmt.Fprintf(w, "id: %d\ndata: %s\n\n", env.Seq, data)
+	return err
+}
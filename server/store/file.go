@@ -0,0 +1,125 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// FileStore is a filesystem-backed Store. Each room's snapshot and
+// event log live under dir, named after the room's ID.
+type FileStore struct {
+	mu  sync.Mutex
+	dir string
+}
+
+// NewFileStore constructs a FileStore rooted at dir, creating it if it
+// doesn't already exist.
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("store: create %s: %w", dir, err)
+	}
+	return &FileStore{dir: dir}, nil
+}
+
+func (s *FileStore) snapshotPath(roomID string) string {
+	return filepath.Join(s.dir, roomID+".snapshot.json")
+}
+
+func (s *FileStore) eventsPath(roomID string) string {
+	return filepath.Join(s.dir, roomID+".events.jsonl")
+}
+
+const metaSuffix = ".meta.json"
+
+func (s *FileStore) metaPath(roomID string) string {
+	return filepath.Join(s.dir, roomID+metaSuffix)
+}
+
+// fileMeta is the JSON form of a room's metadata, written alongside its
+// snapshot so Rooms can report which game a persisted room was running.
+type fileMeta struct {
+	Game string `json:"game"`
+}
+
+// SaveGame implements Store.
+func (s *FileStore) SaveGame(roomID, gameName string, snapshot []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := os.WriteFile(s.snapshotPath(roomID), snapshot, 0o644); err != nil {
+		return fmt.Errorf("store: save %s: %w", roomID, err)
+	}
+
+	meta, err := json.Marshal(fileMeta{Game: gameName})
+	if err != nil {
+		return fmt.Errorf("store: marshal meta for %s: %w", roomID, err)
+	}
+	if err := os.WriteFile(s.metaPath(roomID), meta, 0o644); err != nil {
+		return fmt.Errorf("store: save meta for %s: %w", roomID, err)
+	}
+	return nil
+}
+
+// LoadGame implements Store.
+func (s *FileStore) LoadGame(roomID string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := os.ReadFile(s.snapshotPath(roomID))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("store: load %s: %w", roomID, err)
+	}
+	return data, nil
+}
+
+// AppendEvent implements Store.
+func (s *FileStore) AppendEvent(roomID string, evt []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, err := os.OpenFile(s.eventsPath(roomID), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("store: open event log for %s: %w", roomID, err)
+	}
+	defer f.Close()
+
+	if _, err := f.Write(append(evt, '\n')); err != nil {
+		return fmt.Errorf("store: append event for %s: %w", roomID, err)
+	}
+	return nil
+}
+
+// Rooms implements Store.
+func (s *FileStore) Rooms() ([]RoomRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	matches, err := filepath.Glob(filepath.Join(s.dir, "*"+metaSuffix))
+	if err != nil {
+		return nil, fmt.Errorf("store: list rooms: %w", err)
+	}
+
+	records := make([]RoomRecord, 0, len(matches))
+	for _, path := range matches {
+		roomID := strings.TrimSuffix(filepath.Base(path), metaSuffix)
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("store: read meta for %s: %w", roomID, err)
+		}
+		var meta fileMeta
+		if err := json.Unmarshal(data, &meta); err != nil {
+			return nil, fmt.Errorf("store: unmarshal meta for %s: %w", roomID, err)
+		}
+		records = append(records, RoomRecord{RoomID: roomID, Game: meta.Game})
+	}
+	return records, nil
+}
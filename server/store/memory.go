@@ -0,0 +1,61 @@
+package store
+
+import "sync"
+
+// MemoryStore is an in-memory Store. It does not persist across
+// restarts; useful for development and tests.
+type MemoryStore struct {
+	mu        sync.Mutex
+	snapshots map[string][]byte
+	games     map[string]string
+	events    map[string][][]byte
+}
+
+// NewMemoryStore constructs an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		snapshots: make(map[string][]byte),
+		games:     make(map[string]string),
+		events:    make(map[string][][]byte),
+	}
+}
+
+// SaveGame implements Store.
+func (s *MemoryStore) SaveGame(roomID, gameName string, snapshot []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.snapshots[roomID] = append([]byte(nil), snapshot...)
+	s.games[roomID] = gameName
+	return nil
+}
+
+// LoadGame implements Store.
+func (s *MemoryStore) LoadGame(roomID string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	snap, ok := s.snapshots[roomID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return append([]byte(nil), snap...), nil
+}
+
+// AppendEvent implements Store.
+func (s *MemoryStore) AppendEvent(roomID string, evt []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events[roomID] = append(s.events[roomID], append([]byte(nil), evt...))
+	return nil
+}
+
+// Rooms implements Store.
+func (s *MemoryStore) Rooms() ([]RoomRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	records := make([]RoomRecord, 0, len(s.games))
+	for roomID, game := range s.games {
+		records = append(records, RoomRecord{RoomID: roomID, Game: game})
+	}
+	return records, nil
+}
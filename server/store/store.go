@@ -0,0 +1,37 @@
+// Package store persists room game state so a Game can survive a
+// process restart instead of silently resetting.
+package store
+
+import "errors"
+
+// ErrNotFound is returned by LoadGame when roomID has no saved
+// snapshot.
+var ErrNotFound = errors.New("store: not found")
+
+// RoomRecord identifies a room with a persisted game snapshot and the
+// game it was running, so a server can rebuild its room table after a
+// restart.
+type RoomRecord struct {
+	RoomID string
+	Game   string
+}
+
+// Store saves and loads a room's Game snapshot, and keeps an append-only
+// log of the events a room has dispatched.
+type Store interface {
+	// SaveGame persists snapshot as the latest state for roomID
+	// running gameName, overwriting whatever was saved before.
+	SaveGame(roomID, gameName string, snapshot []byte) error
+
+	// LoadGame returns the last snapshot saved for roomID, or
+	// ErrNotFound if none exists.
+	LoadGame(roomID string) ([]byte, error)
+
+	// AppendEvent records evt as having been dispatched by roomID.
+	AppendEvent(roomID string, evt []byte) error
+
+	// Rooms returns a record for every room with a saved snapshot, so
+	// a server can rebuild its room table on startup instead of only
+	// ever recovering a hardcoded default room.
+	Rooms() ([]RoomRecord, error)
+}
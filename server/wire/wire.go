@@ -0,0 +1,103 @@
+// Package wire defines the tagged-union JSON envelope games use to talk
+// to clients. Every command a client sends and every event a game emits
+// carries a NetTag identifying its concrete Go type, so the envelope can
+// round-trip through JSON without losing type information.
+package wire
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+)
+
+// Envelope is the wire format exchanged with clients: a type tag used
+// to pick the concrete Go type, a monotonic sequence number, and the
+// type-specific body.
+type Envelope struct {
+	Type string          `json:"type"`
+	Seq  int             `json:"seq"`
+	Body json.RawMessage `json:"body"`
+}
+
+// Command is a message a client sends to a Game. NetTag identifies the
+// envelope type used to decode it.
+type Command interface {
+	NetTag() string
+}
+
+// Event is a message a Game sends back to clients. NetTag identifies the
+// envelope type used to encode it, and Target says who should receive it.
+type Event interface {
+	NetTag() string
+
+	// Target returns the player this event should be routed to, or ""
+	// to broadcast it to every subscriber of the room.
+	Target() string
+}
+
+// Broadcast is embedded by events meant for every subscriber of a room.
+type Broadcast struct{}
+
+// Target implements Event.
+func (Broadcast) Target() string { return "" }
+
+// ToPlayer is embedded by events meant for a single player.
+type ToPlayer struct {
+	PlayerID string `json:"-"`
+}
+
+// Target implements Event.
+func (t ToPlayer) Target() string { return t.PlayerID }
+
+// CommandRegistry decodes envelopes into concrete Command values based
+// on their NetTag.
+type CommandRegistry struct {
+	mu        sync.Mutex
+	factories map[string]func() Command
+}
+
+// NewCommandRegistry constructs an empty CommandRegistry.
+func NewCommandRegistry() *CommandRegistry {
+	return &CommandRegistry{factories: make(map[string]func() Command)}
+}
+
+// Register adds a Command factory under its NetTag, overwriting any
+// existing factory registered under the same tag.
+func (cr *CommandRegistry) Register(tag string, factory func() Command) {
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+	cr.factories[tag] = factory
+}
+
+// Decode unmarshals a raw envelope into the concrete Command registered
+// for its type tag.
+func (cr *CommandRegistry) Decode(data []byte) (Command, error) {
+	var env Envelope
+	if err := json.Unmarshal(data, &env); err != nil {
+		return nil, fmt.Errorf("wire: decode envelope: %w", err)
+	}
+
+	cr.mu.Lock()
+	factory, ok := cr.factories[env.Type]
+	cr.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("wire: unregistered command type %q", env.Type)
+	}
+
+	cmd := factory()
+	if len(env.Body) > 0 {
+		if err := json.Unmarshal(env.Body, cmd); err != nil {
+			return nil, fmt.Errorf("wire: decode %q body: %w", env.Type, err)
+		}
+	}
+	return cmd, nil
+}
+
+// Encode wraps evt in an envelope tagged with its NetTag and seq.
+func Encode(seq int, evt Event) ([]byte, error) {
+	body, err := json.Marshal(evt)
+	if err != nil {
+		return nil, fmt.Errorf("wire: encode %q body: %w", evt.NetTag(), err)
+	}
+	return json.Marshal(Envelope{Type: evt.NetTag(), Seq: seq, Body: body})
+}